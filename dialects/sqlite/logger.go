@@ -0,0 +1,188 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Level is a Logger's verbosity, from least to most chatty.
+type Level int
+
+// Logger levels, ordered so that a higher Level is always a superset of a
+// lower one's output.
+const (
+	Silent Level = iota
+	Error
+	Warn
+	Info
+)
+
+// Logger intercepts every SQL statement the dialect runs: the rendered
+// SQL, its expanded arguments, how long it took, how many rows it
+// touched, and any error. Implementations can promote slow statements to
+// Warn via a threshold (see Config.SlowThreshold below).
+type Logger interface {
+	LogMode(Level) Logger
+	Info(ctx context.Context, msg string, args ...interface{})
+	Warn(ctx context.Context, msg string, args ...interface{})
+	Error(ctx context.Context, msg string, args ...interface{})
+	Trace(ctx context.Context, begin time.Time, sql string, args []interface{}, rowsAffected int64, err error)
+}
+
+// Config configures the default Logger returned by NewLogger.
+type Config struct {
+	// SlowThreshold promotes any statement slower than it from Info to
+	// Warn. Zero disables slow-query promotion.
+	SlowThreshold time.Duration
+	// ParameterizedQueries redacts argument values from logged SQL, so
+	// logs are safe to ship somewhere that isn't already access-controlled.
+	ParameterizedQueries bool
+	// LogLevel is the minimum level that gets written.
+	LogLevel Level
+}
+
+// NewLogger returns the dialect's default Logger. write is called with the
+// finished message for every entry at or above config.LogLevel; callers
+// typically pass something like `func(_ Level, msg string) { log.Println(msg) }`.
+func NewLogger(write func(level Level, msg string), config Config) Logger {
+	return &defaultLogger{Config: config, write: write}
+}
+
+type defaultLogger struct {
+	Config
+	write func(level Level, msg string)
+}
+
+func (l *defaultLogger) LogMode(level Level) Logger {
+	clone := *l
+	clone.LogLevel = level
+	return &clone
+}
+
+func (l *defaultLogger) Info(ctx context.Context, msg string, args ...interface{}) {
+	if l.LogLevel >= Info {
+		l.write(Info, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *defaultLogger) Warn(ctx context.Context, msg string, args ...interface{}) {
+	if l.LogLevel >= Warn {
+		l.write(Warn, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *defaultLogger) Error(ctx context.Context, msg string, args ...interface{}) {
+	if l.LogLevel >= Error {
+		l.write(Error, fmt.Sprintf(msg, args...))
+	}
+}
+
+func (l *defaultLogger) Trace(ctx context.Context, begin time.Time, sqlStr string, args []interface{}, rowsAffected int64, err error) {
+	if l.LogLevel <= Silent {
+		return
+	}
+
+	elapsed := time.Since(begin)
+	rendered := sqlStr
+	if !l.ParameterizedQueries {
+		rendered = renderSQL(sqlStr, args)
+	}
+	caller := callerLine()
+
+	switch {
+	case err != nil && l.LogLevel >= Error:
+		l.write(Error, fmt.Sprintf("%s [%s] [rows:%d] %s -- %s", caller, elapsed, rowsAffected, rendered, err))
+	case l.SlowThreshold != 0 && elapsed > l.SlowThreshold && l.LogLevel >= Warn:
+		l.write(Warn, fmt.Sprintf("SLOW SQL >= %s\n%s [%s] [rows:%d] %s", l.SlowThreshold, caller, elapsed, rowsAffected, rendered))
+	case l.LogLevel >= Info:
+		l.write(Info, fmt.Sprintf("%s [%s] [rows:%d] %s", caller, elapsed, rowsAffected, rendered))
+	}
+}
+
+// renderSQL substitutes each `?` placeholder with its argument, for
+// human-readable (non-parameterized) log output.
+func renderSQL(sqlStr string, args []interface{}) string {
+	rendered := sqlStr
+	for _, arg := range args {
+		rendered = strings.Replace(rendered, "?", fmt.Sprintf("%v", arg), 1)
+	}
+	return rendered
+}
+
+// callerLine returns the first stack frame outside this package, so a log
+// line points at the application code that issued the query.
+func callerLine() string {
+	for i := 2; i < 15; i++ {
+		_, file, line, ok := runtime.Caller(i)
+		if !ok {
+			break
+		}
+		if !strings.Contains(file, "/dialects/sqlite/") {
+			return file + ":" + strconv.Itoa(line)
+		}
+	}
+	return ""
+}
+
+// contextFrom returns tx's request-scoped context, if it set one via
+// db.WithContext, falling back to context.Background().
+func contextFrom(tx *gorm.DB) context.Context {
+	if tx != nil && tx.Statement != nil && tx.Statement.Context != nil {
+		return tx.Statement.Context
+	}
+	return context.Background()
+}
+
+// exec runs query through dialect.DB.ExecContext, tracing it through
+// dialect.Logger when one is configured.
+func (dialect *Dialect) exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return dialect.traceExec(ctx, func(query string, args ...interface{}) (sql.Result, error) {
+		return dialect.DB.ExecContext(ctx, query, args...)
+	}, query, args)
+}
+
+// query runs query through dialect.DB.QueryContext, tracing it through
+// dialect.Logger when one is configured.
+func (dialect *Dialect) query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	begin := time.Now()
+	rows, err := dialect.DB.QueryContext(ctx, query, args...)
+	if dialect.Logger != nil {
+		dialect.Logger.Trace(ctx, begin, query, args, 0, err)
+	}
+	return rows, err
+}
+
+// queryRow runs query through dialect.DB.QueryRowContext, tracing it
+// through dialect.Logger when one is configured. Since *sql.Row defers
+// its error until Scan, the trace can't report one here.
+func (dialect *Dialect) queryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	begin := time.Now()
+	row := dialect.DB.QueryRowContext(ctx, query, args...)
+	if dialect.Logger != nil {
+		dialect.Logger.Trace(ctx, begin, query, args, 0, nil)
+	}
+	return row
+}
+
+// traceExec runs exec(query, args...) and, when dialect.Logger is
+// configured, traces it. It's shared by exec and by call sites that must
+// run inside an existing *sql.Tx (whose Exec has the same signature).
+func (dialect *Dialect) traceExec(ctx context.Context, exec func(query string, args ...interface{}) (sql.Result, error), query string, args []interface{}) (sql.Result, error) {
+	begin := time.Now()
+	result, err := exec(query, args...)
+	if dialect.Logger != nil {
+		var rowsAffected int64
+		if result != nil {
+			rowsAffected, _ = result.RowsAffected()
+		}
+		dialect.Logger.Trace(ctx, begin, query, args, rowsAffected, err)
+	}
+	return result, err
+}
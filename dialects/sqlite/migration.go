@@ -0,0 +1,318 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jinzhu/gorm/schema"
+)
+
+// columnInfo mirrors a row of `PRAGMA table_info(table)`.
+type columnInfo struct {
+	cid        int
+	name       string
+	dataType   string
+	notNull    bool
+	defaultVal sql.NullString
+	pk         int
+}
+
+// foreignKeyInfo mirrors a row of `PRAGMA foreign_key_list(table)`.
+type foreignKeyInfo struct {
+	id       int
+	seq      int
+	table    string
+	from     string
+	to       string
+	onUpdate string
+	onDelete string
+}
+
+// indexInfo mirrors a row of `PRAGMA index_list(table)`.
+type indexInfo struct {
+	seq    int
+	name   string
+	unique bool
+}
+
+// HasTable returns whether name exists in sqlite_master. Schema inspection
+// has no request-scoped context to inherit, so it runs against
+// context.Background(), same as AutoMigrate and CreateTable below.
+func (dialect *Dialect) HasTable(name string) bool {
+	var count int
+	row := dialect.queryRow(context.Background(), "SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name)
+	if err := row.Scan(&count); err != nil {
+		return false
+	}
+	return count > 0
+}
+
+// columnsOf returns the existing columns of table, keyed by lowercased name.
+func (dialect *Dialect) columnsOf(ctx context.Context, table string) (map[string]columnInfo, error) {
+	rows, err := dialect.query(ctx, fmt.Sprintf("PRAGMA table_info(%s)", dialect.Quote(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := map[string]columnInfo{}
+	for rows.Next() {
+		var c columnInfo
+		if err := rows.Scan(&c.cid, &c.name, &c.dataType, &c.notNull, &c.defaultVal, &c.pk); err != nil {
+			return nil, err
+		}
+		columns[strings.ToLower(c.name)] = c
+	}
+	return columns, rows.Err()
+}
+
+// foreignKeysOf returns the existing foreign keys of table.
+func (dialect *Dialect) foreignKeysOf(ctx context.Context, table string) ([]foreignKeyInfo, error) {
+	rows, err := dialect.query(ctx, fmt.Sprintf("PRAGMA foreign_key_list(%s)", dialect.Quote(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fks []foreignKeyInfo
+	for rows.Next() {
+		var fk foreignKeyInfo
+		var match, onUpdate, onDelete sql.NullString
+		if err := rows.Scan(&fk.id, &fk.seq, &fk.table, &fk.from, &fk.to, &onUpdate, &onDelete, &match); err != nil {
+			return nil, err
+		}
+		fk.onUpdate, fk.onDelete = onUpdate.String, onDelete.String
+		fks = append(fks, fk)
+	}
+	return fks, rows.Err()
+}
+
+// indexesOf returns the existing indexes of table.
+func (dialect *Dialect) indexesOf(ctx context.Context, table string) ([]indexInfo, error) {
+	rows, err := dialect.query(ctx, fmt.Sprintf("PRAGMA index_list(%s)", dialect.Quote(table)))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var indexes []indexInfo
+	for rows.Next() {
+		var idx indexInfo
+		var origin string
+		var partial bool
+		if err := rows.Scan(&idx.seq, &idx.name, &idx.unique, &origin, &partial); err != nil {
+			return nil, err
+		}
+		indexes = append(indexes, idx)
+	}
+	return indexes, rows.Err()
+}
+
+// AutoMigrate converges the table backing value with the struct's schema,
+// creating it if missing, adding missing columns and indexes, and rebuilding
+// the table when an existing column needs a type it cannot ALTER to.
+func (dialect *Dialect) AutoMigrate(value interface{}) error {
+	ctx := context.Background()
+	s := schema.Parse(value)
+	table := s.TableName
+
+	if !dialect.HasTable(table) {
+		return dialect.CreateTable(value)
+	}
+
+	existing, err := dialect.columnsOf(ctx, table)
+	if err != nil {
+		return err
+	}
+
+	var rebuild bool
+	for _, field := range s.Fields {
+		current, ok := existing[strings.ToLower(field.DBName)]
+		if !ok {
+			if _, err := dialect.exec(ctx, fmt.Sprintf(
+				"ALTER TABLE %s ADD COLUMN %s", dialect.Quote(table), dialect.columnDefinition(field),
+			)); err != nil {
+				return err
+			}
+			continue
+		}
+		if !strings.EqualFold(current.dataType, field.DataType) || field.NotNull != current.notNull {
+			rebuild = true
+		}
+	}
+
+	if rebuild {
+		if err := dialect.rebuildTable(ctx, s); err != nil {
+			return err
+		}
+	}
+
+	return dialect.createMissingIndexes(ctx, s)
+}
+
+// CreateTable emits CREATE TABLE for value's schema, followed by its indexes.
+func (dialect *Dialect) CreateTable(value interface{}) error {
+	ctx := context.Background()
+	s := schema.Parse(value)
+
+	var definitions []string
+	var primaryKeys []string
+	for _, field := range s.Fields {
+		definitions = append(definitions, dialect.columnDefinition(field))
+		if field.IsPrimaryKey {
+			primaryKeys = append(primaryKeys, dialect.Quote(field.DBName))
+		}
+	}
+	if len(primaryKeys) > 0 {
+		definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaryKeys, ", ")))
+	}
+	for _, fk := range s.ForeignKeys {
+		definitions = append(definitions, fmt.Sprintf(
+			"FOREIGN KEY (%s) REFERENCES %s(%s)",
+			dialect.Quote(fk.Field), dialect.Quote(fk.ReferenceTable), dialect.Quote(fk.ReferenceField),
+		))
+	}
+
+	createSQL := fmt.Sprintf("CREATE TABLE %s (%s)", dialect.Quote(s.TableName), strings.Join(definitions, ", "))
+	if _, err := dialect.exec(ctx, createSQL); err != nil {
+		return err
+	}
+
+	return dialect.createMissingIndexes(ctx, s)
+}
+
+// columnDefinition renders a single column's CREATE/ALTER fragment.
+func (dialect *Dialect) columnDefinition(field *schema.Field) string {
+	def := fmt.Sprintf("%s %s", dialect.Quote(field.DBName), field.DataType)
+	if field.NotNull {
+		def += " NOT NULL"
+	}
+	if field.HasDefaultValue {
+		def += fmt.Sprintf(" DEFAULT %s", field.DefaultValue)
+	}
+	return def
+}
+
+// createMissingIndexes creates any index declared on s that the table lacks.
+func (dialect *Dialect) createMissingIndexes(ctx context.Context, s *schema.Schema) error {
+	existing, err := dialect.indexesOf(ctx, s.TableName)
+	if err != nil {
+		return err
+	}
+	have := map[string]bool{}
+	for _, idx := range existing {
+		have[idx.name] = true
+	}
+
+	for _, idx := range s.Indexes {
+		if have[idx.Name] {
+			continue
+		}
+		unique := ""
+		if idx.Unique {
+			unique = "UNIQUE "
+		}
+		columns := make([]string, len(idx.Fields))
+		for i, f := range idx.Fields {
+			columns[i] = dialect.Quote(f)
+		}
+		createSQL := fmt.Sprintf("CREATE %sINDEX %s ON %s (%s)",
+			unique, dialect.Quote(idx.Name), dialect.Quote(s.TableName), strings.Join(columns, ", "))
+		if _, err := dialect.exec(ctx, createSQL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rebuildTable performs SQLite's rename-copy-drop dance for column changes
+// that ALTER TABLE cannot express (type changes, dropped NOT NULL, etc).
+func (dialect *Dialect) rebuildTable(ctx context.Context, s *schema.Schema) (err error) {
+	table := s.TableName
+	tmpTable := table + "_gorm_migration_tmp"
+
+	tx, err := dialect.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	execTx := execOnTx(ctx, tx)
+
+	if _, err = dialect.traceExec(ctx, execTx, "PRAGMA foreign_keys = OFF", nil); err != nil {
+		return err
+	}
+	renameSQL := fmt.Sprintf("ALTER TABLE %s RENAME TO %s", dialect.Quote(table), dialect.Quote(tmpTable))
+	if _, err = dialect.traceExec(ctx, execTx, renameSQL, nil); err != nil {
+		return err
+	}
+
+	tmpDialect := &Dialect{DB: dialect.DB, Logger: dialect.Logger}
+	if err = tmpDialect.createTableOn(ctx, tx, s); err != nil {
+		return err
+	}
+
+	columns := make([]string, len(s.Fields))
+	for i, field := range s.Fields {
+		columns[i] = dialect.Quote(field.DBName)
+	}
+	columnList := strings.Join(columns, ", ")
+	copySQL := fmt.Sprintf("INSERT INTO %s (%s) SELECT %s FROM %s",
+		dialect.Quote(table), columnList, columnList, dialect.Quote(tmpTable))
+	if _, err = dialect.traceExec(ctx, execTx, copySQL, nil); err != nil {
+		return err
+	}
+
+	dropSQL := fmt.Sprintf("DROP TABLE %s", dialect.Quote(tmpTable))
+	if _, err = dialect.traceExec(ctx, execTx, dropSQL, nil); err != nil {
+		return err
+	}
+	if _, err = dialect.traceExec(ctx, execTx, "PRAGMA foreign_keys = ON", nil); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// execOnTx adapts tx.ExecContext to the `func(query string, args
+// ...interface{}) (sql.Result, error)` shape traceExec expects, so
+// statements run inside a transaction still honor ctx cancellation
+// instead of silently running against context.Background() the way
+// tx.Exec does internally.
+func execOnTx(ctx context.Context, tx *sql.Tx) func(query string, args ...interface{}) (sql.Result, error) {
+	return func(query string, args ...interface{}) (sql.Result, error) {
+		return tx.ExecContext(ctx, query, args...)
+	}
+}
+
+// createTableOn emits CreateTable's CREATE TABLE statement against tx
+// instead of dialect.DB, for use inside rebuildTable's transaction.
+func (dialect *Dialect) createTableOn(ctx context.Context, tx *sql.Tx, s *schema.Schema) error {
+	var definitions []string
+	var primaryKeys []string
+	for _, field := range s.Fields {
+		definitions = append(definitions, dialect.columnDefinition(field))
+		if field.IsPrimaryKey {
+			primaryKeys = append(primaryKeys, dialect.Quote(field.DBName))
+		}
+	}
+	if len(primaryKeys) > 0 {
+		definitions = append(definitions, fmt.Sprintf("PRIMARY KEY (%s)", strings.Join(primaryKeys, ", ")))
+	}
+	for _, fk := range s.ForeignKeys {
+		definitions = append(definitions, fmt.Sprintf(
+			"FOREIGN KEY (%s) REFERENCES %s(%s)",
+			dialect.Quote(fk.Field), dialect.Quote(fk.ReferenceTable), dialect.Quote(fk.ReferenceField),
+		))
+	}
+
+	createSQL := fmt.Sprintf("CREATE TABLE %s (%s)", dialect.Quote(s.TableName), strings.Join(definitions, ", "))
+	_, err := dialect.traceExec(ctx, execOnTx(ctx, tx), createSQL, nil)
+	return err
+}
@@ -0,0 +1,187 @@
+package sqlite
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/jinzhu/gorm/model"
+)
+
+// namedParam is one `:name` / `@name` token found in a named query, along
+// with where it should be substituted.
+type namedParam struct {
+	name  string
+	start int
+	end   int
+}
+
+// NamedQuery is the dialect-side half of *gorm.DB's NamedQuery: it runs
+// query, with `:name`/`@name` placeholders bound from arg, and scans the
+// result into tx.Statement.Dest. arg may be a map[string]interface{} or a
+// struct (gorm column tags are honored). Callers reach this through
+// tx.NamedQuery(query, arg), which dispatches to it when tx.Dialect
+// implements gorm.NamedQuerier.
+//
+// Insert, Query, Update, and Delete build their SQL from an already-parsed
+// Statement (conditions, assignments, ...), not from a raw query string, so
+// there's no named-parameter text for this rewriting to apply to there;
+// NamedQuery/NamedExec are the entry points for callers who do start from
+// raw SQL.
+func (dialect *Dialect) NamedQuery(tx *gorm.DB, query string, arg interface{}) error {
+	rewritten, args, err := bindNamedArgs(query, arg)
+	if err != nil {
+		return err
+	}
+
+	rows, err := dialect.query(contextFrom(tx), rewritten, args...)
+	if err != nil {
+		return err
+	}
+	return scanRows(rows, tx.Statement.Dest)
+}
+
+// NamedExec is the dialect-side half of *gorm.DB's NamedExec: it runs
+// query, with `:name`/`@name` placeholders bound from arg, and records
+// RowsAffected on tx. arg may be a map[string]interface{} or a struct
+// (gorm column tags are honored). Callers reach this through
+// tx.NamedExec(query, arg), which dispatches to it when tx.Dialect
+// implements gorm.NamedExecer.
+func (dialect *Dialect) NamedExec(tx *gorm.DB, query string, arg interface{}) error {
+	rewritten, args, err := bindNamedArgs(query, arg)
+	if err != nil {
+		return err
+	}
+
+	result, err := dialect.exec(contextFrom(tx), rewritten, args...)
+	if err != nil {
+		return err
+	}
+	tx.RowsAffected, err = result.RowsAffected()
+	return err
+}
+
+// bindNamedArgs rewrites a query containing `:name`/`@name` placeholders
+// into one using positional `?` placeholders, returning the args in the
+// matching order. A named value that is a slice or array (other than a
+// byte slice or array, which is bound as one value) is expanded into a
+// comma-separated run of `?` placeholders, so `IN (:ids)` works with
+// `ids []int` without the caller building the IN-list by hand.
+func bindNamedArgs(query string, arg interface{}) (string, []interface{}, error) {
+	params := parseNamedParams(query)
+	if len(params) == 0 {
+		return query, nil, nil
+	}
+
+	lookup, err := namedArgLookup(arg)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var (
+		out  strings.Builder
+		args []interface{}
+		pos  int
+	)
+	for _, p := range params {
+		value, ok := lookup(p.name)
+		if !ok {
+			return "", nil, fmt.Errorf("sqlite: missing named argument %q", p.name)
+		}
+
+		out.WriteString(query[pos:p.start])
+
+		rv := reflect.ValueOf(value)
+		if rv.IsValid() && (rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array) && rv.Type().Elem().Kind() != reflect.Uint8 {
+			placeholders := make([]string, rv.Len())
+			for i := 0; i < rv.Len(); i++ {
+				placeholders[i] = "?"
+				args = append(args, rv.Index(i).Interface())
+			}
+			out.WriteString(strings.Join(placeholders, ", "))
+		} else {
+			out.WriteString("?")
+			args = append(args, value)
+		}
+
+		pos = p.end
+	}
+	out.WriteString(query[pos:])
+
+	return out.String(), args, nil
+}
+
+// parseNamedParams scans query for `:name` and `@name` tokens, skipping
+// `::` (postgres-style casts, harmless to preserve) and occurrences inside
+// single-quoted string literals.
+func parseNamedParams(query string) []namedParam {
+	var params []namedParam
+	inString := false
+
+	for i := 0; i < len(query); i++ {
+		switch {
+		case query[i] == '\'':
+			inString = !inString
+		case inString:
+			// skip
+		case query[i] == ':' || query[i] == '@':
+			if query[i] == ':' && i+1 < len(query) && query[i+1] == ':' {
+				i++
+				continue
+			}
+			j := i + 1
+			for j < len(query) && isNameByte(query[j]) {
+				j++
+			}
+			if j > i+1 {
+				params = append(params, namedParam{name: query[i+1 : j], start: i, end: j})
+				i = j - 1
+			}
+		}
+	}
+	return params
+}
+
+func isNameByte(b byte) bool {
+	return b == '_' || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
+
+// namedArgLookup returns a function that resolves a named parameter to its
+// value, either from a map or from a struct's exported fields (respecting
+// `gorm:"column:..."` tags).
+func namedArgLookup(arg interface{}) (func(name string) (interface{}, bool), error) {
+	if m, ok := arg.(map[string]interface{}); ok {
+		return func(name string) (interface{}, bool) {
+			v, ok := m[name]
+			return v, ok
+		}, nil
+	}
+
+	rv := reflect.ValueOf(arg)
+	for rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("sqlite: named argument must be a map or struct, got %T", arg)
+	}
+	if !rv.CanAddr() {
+		// arg was passed by value (e.g. NamedExec(query, MyArgs{...})), so
+		// reflect.ValueOf(arg) is never addressable; model.Parse needs a
+		// pointer, so operate on an addressable copy instead.
+		addr := reflect.New(rv.Type())
+		addr.Elem().Set(rv)
+		rv = addr.Elem()
+	}
+
+	fieldsMap := model.Parse(rv.Addr().Interface()).FieldsMap()
+	return func(name string) (interface{}, bool) {
+		if f, ok := fieldsMap[strings.ToLower(name)]; ok {
+			return f.Value.Interface(), true
+		}
+		if f, ok := fieldsMap[name]; ok {
+			return f.Value.Interface(), true
+		}
+		return nil, false
+	}, nil
+}
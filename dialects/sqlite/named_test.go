@@ -0,0 +1,51 @@
+package sqlite
+
+import (
+	"testing"
+
+	"github.com/jinzhu/gorm"
+)
+
+// namedArgs is the struct-literal argument used by TestNamedExecStructArg
+// and TestNamedArgLookupStructArg below.
+type namedArgs struct {
+	Name string
+	Age  int
+}
+
+func TestNamedArgLookupStructArg(t *testing.T) {
+	// Passed by value, not by pointer: reflect.ValueOf(arg) is never
+	// addressable here, which is exactly what namedArgLookup must handle.
+	lookup, err := namedArgLookup(namedArgs{Name: "gopher", Age: 10})
+	if err != nil {
+		t.Fatalf("namedArgLookup: %v", err)
+	}
+
+	name, ok := lookup("Name")
+	if !ok || name != "gopher" {
+		t.Fatalf("lookup(Name) = %v, %v; want gopher, true", name, ok)
+	}
+	age, ok := lookup("Age")
+	if !ok || age != 10 {
+		t.Fatalf("lookup(Age) = %v, %v; want 10, true", age, ok)
+	}
+}
+
+func TestNamedExecStructArg(t *testing.T) {
+	db, conn := newFakeDB(t, 0)
+	defer db.Close()
+	conn.rowsPerExec = []int64{1}
+
+	dialect := &Dialect{DB: db}
+	tx := &gorm.DB{Statement: &gorm.Statement{}}
+
+	// Struct literal, not &namedArgs{...}: this is the call shape the
+	// doc comment on NamedExec advertises.
+	err := dialect.NamedExec(tx, "UPDATE users SET name = :Name, age = :Age WHERE id = 1", namedArgs{Name: "gopher", Age: 10})
+	if err != nil {
+		t.Fatalf("NamedExec: %v", err)
+	}
+	if tx.RowsAffected != 1 {
+		t.Fatalf("RowsAffected = %d, want 1", tx.RowsAffected)
+	}
+}
@@ -0,0 +1,285 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// scanPlanKey caches a struct scan plan per (type, column-set) pair, since
+// the same query shape is typically re-run many times with the same
+// destination type.
+type scanPlanKey struct {
+	typ     reflect.Type
+	columns string
+}
+
+// scanPlan is the precomputed mapping from result columns to destination
+// struct fields for one scanPlanKey.
+type scanPlan struct {
+	// fieldIndexByColumn[i] is the reflect.Value.FieldByIndex path for
+	// columns[i], or nil if the column has no matching field and should be
+	// discarded.
+	fieldIndexByColumn [][]int
+}
+
+var scanPlanCache sync.Map // scanPlanKey -> *scanPlan
+
+// customScanners lets callers teach scanRows how to populate a field type
+// that doesn't implement sql.Scanner itself.
+var customScanners sync.Map // reflect.Type -> func(reflect.Value, interface{}) error
+
+// RegisterScanner installs fn as the way to populate any struct field of
+// type t when it is the destination of a scanned column. It is meant for
+// third-party types that can't implement database/sql.Scanner themselves.
+func RegisterScanner(t reflect.Type, fn func(dest reflect.Value, src interface{}) error) {
+	customScanners.Store(t, fn)
+}
+
+// customScanShim adapts a registered custom scanner to database/sql.Scanner
+// so it can be handed straight to rows.Scan.
+type customScanShim struct {
+	dest reflect.Value
+	fn   func(reflect.Value, interface{}) error
+}
+
+func (s customScanShim) Scan(src interface{}) error { return s.fn(s.dest, src) }
+
+// scanRows reads every row of rows into dest, which must be a pointer to
+// one of: a struct, a map[string]interface{}, a slice of either of those
+// (or pointers to them), or a single addressable primitive (when the
+// query selects exactly one column).
+func scanRows(rows *sql.Rows, dest interface{}) error {
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("sqlite: scan destination must be a non-nil pointer, got %T", dest)
+	}
+	elem := rv.Elem()
+
+	if elem.Kind() == reflect.Slice {
+		return scanRowsIntoSlice(rows, columns, elem)
+	}
+	return scanRowsIntoOne(rows, columns, elem)
+}
+
+// scanRowsIntoSlice scans every row into a freshly appended element of
+// slice (whose element type may itself be a pointer type).
+func scanRowsIntoSlice(rows *sql.Rows, columns []string, slice reflect.Value) error {
+	elemType := slice.Type().Elem()
+	baseType := elemType
+	isPtr := baseType.Kind() == reflect.Ptr
+	if isPtr {
+		baseType = baseType.Elem()
+	}
+
+	slice.Set(reflect.MakeSlice(slice.Type(), 0, 0))
+
+	for rows.Next() {
+		item := reflect.New(baseType).Elem()
+
+		scanner, err := buildRowScanner(columns, item)
+		if err != nil {
+			return err
+		}
+		if err := rows.Scan(scanner.ptrs...); err != nil {
+			return err
+		}
+		if scanner.finalize != nil {
+			if err := scanner.finalize(); err != nil {
+				return err
+			}
+		}
+
+		if isPtr {
+			ptr := reflect.New(baseType)
+			ptr.Elem().Set(item)
+			slice.Set(reflect.Append(slice, ptr))
+		} else {
+			slice.Set(reflect.Append(slice, item))
+		}
+	}
+	return rows.Err()
+}
+
+// scanRowsIntoOne scans the first row (if any) into elem.
+func scanRowsIntoOne(rows *sql.Rows, columns []string, elem reflect.Value) error {
+	if !rows.Next() {
+		return rows.Err()
+	}
+
+	scanner, err := buildRowScanner(columns, elem)
+	if err != nil {
+		return err
+	}
+	if err := rows.Scan(scanner.ptrs...); err != nil {
+		return err
+	}
+	if scanner.finalize != nil {
+		return scanner.finalize()
+	}
+	return nil
+}
+
+// rowScanner is the set of addressable destinations for one row's worth of
+// columns, plus optional cleanup to run once rows.Scan has populated them
+// (used for map destinations, which can't be addressed directly).
+type rowScanner struct {
+	ptrs     []interface{}
+	finalize func() error
+}
+
+// buildRowScanner builds the scan destinations for one row, dispatching on
+// value's kind.
+func buildRowScanner(columns []string, value reflect.Value) (*rowScanner, error) {
+	switch value.Kind() {
+	case reflect.Ptr:
+		if value.IsNil() {
+			value.Set(reflect.New(value.Type().Elem()))
+		}
+		return buildRowScanner(columns, value.Elem())
+	case reflect.Map:
+		return buildMapScanner(columns, value), nil
+	case reflect.Struct:
+		return buildStructScanner(columns, value)
+	default:
+		if len(columns) != 1 {
+			return nil, fmt.Errorf("sqlite: cannot scan %d columns into %s", len(columns), value.Type())
+		}
+		if !value.CanAddr() {
+			return nil, fmt.Errorf("sqlite: scan destination %s is not addressable", value.Type())
+		}
+		return &rowScanner{ptrs: []interface{}{value.Addr().Interface()}}, nil
+	}
+}
+
+// buildMapScanner scans each column into a fresh interface{} and, once
+// rows.Scan has filled them in, copies them into value by key.
+func buildMapScanner(columns []string, value reflect.Value) *rowScanner {
+	if value.IsNil() {
+		value.Set(reflect.MakeMap(value.Type()))
+	}
+
+	scanned := make([]interface{}, len(columns))
+	ptrs := make([]interface{}, len(columns))
+	for i := range columns {
+		ptrs[i] = &scanned[i]
+	}
+
+	return &rowScanner{
+		ptrs: ptrs,
+		finalize: func() error {
+			for i, column := range columns {
+				value.SetMapIndex(reflect.ValueOf(column), reflect.ValueOf(scanned[i]))
+			}
+			return nil
+		},
+	}
+}
+
+// buildStructScanner resolves value's cached scanPlan and produces
+// addressable pointers (or registered-scanner shims) for every column,
+// allocating through embedded struct pointers along the way.
+func buildStructScanner(columns []string, value reflect.Value) (*rowScanner, error) {
+	plan := structScanPlan(value.Type(), columns)
+
+	var ignored interface{}
+	ptrs := make([]interface{}, len(columns))
+	for i, path := range plan.fieldIndexByColumn {
+		if path == nil {
+			ptrs[i] = &ignored
+			continue
+		}
+
+		field := fieldByIndexAlloc(value, path)
+		if fn, ok := customScanners.Load(field.Type()); ok {
+			ptrs[i] = customScanShim{dest: field, fn: fn.(func(reflect.Value, interface{}) error)}
+			continue
+		}
+		ptrs[i] = field.Addr().Interface()
+	}
+	return &rowScanner{ptrs: ptrs}, nil
+}
+
+// structScanPlan returns the cached column->field mapping for (t, columns),
+// computing and storing it on first use.
+func structScanPlan(t reflect.Type, columns []string) *scanPlan {
+	key := scanPlanKey{typ: t, columns: strings.Join(columns, "\x00")}
+	if cached, ok := scanPlanCache.Load(key); ok {
+		return cached.(*scanPlan)
+	}
+
+	fieldsByColumn := map[string][]int{}
+	collectScanFields(t, nil, fieldsByColumn)
+
+	plan := &scanPlan{fieldIndexByColumn: make([][]int, len(columns))}
+	for i, column := range columns {
+		plan.fieldIndexByColumn[i] = fieldsByColumn[strings.ToLower(column)]
+	}
+
+	scanPlanCache.Store(key, plan)
+	return plan
+}
+
+// collectScanFields walks t's fields, recursing into anonymous
+// (embedded) struct fields so their columns are addressable from the
+// outer type, and records the first field that claims each column name.
+func collectScanFields(t reflect.Type, prefix []int, out map[string][]int) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue // unexported
+		}
+
+		path := append(append([]int{}, prefix...), i)
+
+		if field.Anonymous {
+			embeddedType := field.Type
+			for embeddedType.Kind() == reflect.Ptr {
+				embeddedType = embeddedType.Elem()
+			}
+			if embeddedType.Kind() == reflect.Struct {
+				collectScanFields(embeddedType, path, out)
+				continue
+			}
+		}
+
+		column := columnNameForField(field)
+		if _, exists := out[column]; !exists {
+			out[column] = path
+		}
+	}
+}
+
+// columnNameForField returns field's column name: the `gorm:"column:..."`
+// tag override if present, otherwise its lowercased Go name.
+func columnNameForField(field reflect.StructField) string {
+	for _, part := range strings.Split(field.Tag.Get("gorm"), ";") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if strings.HasPrefix(part, "column:") {
+			return strings.TrimPrefix(part, "column:")
+		}
+	}
+	return strings.ToLower(field.Name)
+}
+
+// fieldByIndexAlloc is reflect.Value.FieldByIndex, except it allocates
+// through nil embedded struct pointers instead of panicking.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
@@ -2,20 +2,23 @@ package sqlite
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"reflect"
+	"sort"
+	"strings"
 
 	"github.com/jinzhu/gorm"
 	"github.com/jinzhu/gorm/dialects/common/sqlbuilder"
 	"github.com/jinzhu/gorm/model"
-	"github.com/jinzhu/gorm/schema"
 )
 
 // Dialect Sqlite3 Dialect for GORM
 type Dialect struct {
 	DB *sql.DB
+	// Logger, when set, is traced for every statement this dialect runs.
+	Logger Logger
 }
 
 // Quote quote for value
@@ -25,17 +28,20 @@ func (dialect Dialect) Quote(name string) string {
 
 // Insert insert
 func (dialect *Dialect) Insert(tx *gorm.DB) (err error) {
+	clauses, err := sqlbuilder.Build(tx)
+	if err != nil {
+		return err
+	}
+
 	var (
-		args            []interface{}
-		assignmentsChan = sqlbuilder.GetAssignmentFields(tx)
-		tableNameChan   = sqlbuilder.GetTable(tx)
-		primaryFields   []*model.Field
+		args          []interface{}
+		primaryFields []*model.Field
 	)
 
 	s := bytes.NewBufferString("INSERT INTO ")
-	s.WriteString(dialect.Quote(<-tableNameChan))
+	s.WriteString(dialect.Quote(clauses.Table))
 
-	if assignments := <-assignmentsChan; len(assignments) > 0 {
+	if assignments := clauses.Assignments; len(assignments) > 0 {
 		columns := []string{}
 
 		// Write columns (column1, column2, column3)
@@ -86,7 +92,7 @@ func (dialect *Dialect) Insert(tx *gorm.DB) (err error) {
 		s.WriteString(" DEFAULT VALUES")
 	}
 
-	result, err := dialect.DB.Exec(s.String(), args...)
+	result, err := dialect.exec(contextFrom(tx), s.String(), args...)
 
 	if err == nil {
 		var lastInsertID int64
@@ -104,16 +110,12 @@ func (dialect *Dialect) Insert(tx *gorm.DB) (err error) {
 
 // Query query
 func (dialect *Dialect) Query(tx *gorm.DB) (err error) {
-	var (
-		args           []interface{}
-		tableNameChan  = sqlbuilder.GetTable(tx)
-		joinChan       = sqlbuilder.BuildJoinCondition(tx)
-		conditionsChan = sqlbuilder.BuildConditions(tx)
-		groupChan      = sqlbuilder.BuildGroupCondition(tx)
-		orderChan      = sqlbuilder.BuildOrderCondition(tx)
-		limitChan      = sqlbuilder.BuildLimitCondition(tx)
-	)
+	clauses, err := sqlbuilder.Build(tx)
+	if err != nil {
+		return err
+	}
 
+	var args []interface{}
 	s := bytes.NewBufferString("SELECT ")
 
 	// FIXME quote, add table
@@ -132,36 +134,38 @@ func (dialect *Dialect) Query(tx *gorm.DB) (err error) {
 	}
 
 	s.WriteString(" FROM ")
-	s.WriteString(dialect.Quote(<-tableNameChan))
+	s.WriteString(dialect.Quote(clauses.Table))
+
+	if clauses.Join != nil {
+		_, err = clauses.Join.SQL.WriteTo(s)
+		args = append(args, clauses.Join.Args...)
+	}
 
-	// Join SQL
-	if builder := <-joinChan; builder != nil {
-		_, err = builder.SQL.WriteTo(s)
-		args = append(args, builder.Args...)
+	if clauses.Conditions != nil {
+		_, err = clauses.Conditions.SQL.WriteTo(s)
+		args = append(args, clauses.Conditions.Args...)
 	}
 
-	if len(tx.Statement.Conditions) > 0 {
-		builder := <-conditionsChan
-		_, err = builder.SQL.WriteTo(s)
-		args = append(args, builder.Args...)
+	if clauses.Group != nil {
+		_, err = clauses.Group.SQL.WriteTo(s)
+		args = append(args, clauses.Group.Args...)
 	}
 
-	if builder := <-groupChan; builder != nil {
-		_, err = builder.SQL.WriteTo(s)
-		args = append(args, builder.Args...)
+	if clauses.Order != nil {
+		_, err = clauses.Order.SQL.WriteTo(s)
+		args = append(args, clauses.Order.Args...)
 	}
 
-	if builder := <-orderChan; builder != nil {
-		_, err = builder.SQL.WriteTo(s)
-		args = append(args, builder.Args...)
+	if clauses.Limit != nil {
+		_, err = clauses.Limit.SQL.WriteTo(s)
+		args = append(args, clauses.Limit.Args...)
 	}
 
-	if builder := <-limitChan; builder != nil {
-		_, err = builder.SQL.WriteTo(s)
-		args = append(args, builder.Args...)
+	if err != nil {
+		return err
 	}
 
-	rows, err := dialect.DB.Query(s.String(), args...)
+	rows, err := dialect.query(contextFrom(tx), s.String(), args...)
 
 	if err == nil {
 		err = scanRows(rows, tx.Statement.Dest)
@@ -170,176 +174,292 @@ func (dialect *Dialect) Query(tx *gorm.DB) (err error) {
 	return
 }
 
-func scanRows(rows *sql.Rows, values interface{}) (err error) {
-	var (
-		isSlice bool
-		results = indirect(reflect.ValueOf(values))
-	)
-	columns, err := rows.Columns()
+// scanRows is implemented in scan.go.
 
-	if kind := results.Kind(); kind == reflect.Slice {
-		isSlice = true
-		results.Set(reflect.MakeSlice(results.Type().Elem(), 0, 0))
+// Update update
+func (dialect *Dialect) Update(tx *gorm.DB) (err error) {
+	clauses, err := sqlbuilder.Build(tx)
+	if err != nil {
+		return err
 	}
 
-	for rows.Next() {
-		elem := results
-		if isSlice {
-			elem = reflect.New(results.Type().Elem()).Elem()
+	if len(clauses.Assignments) > 1 {
+		return dialect.batchUpdate(tx, clauses.Table, clauses.Assignments)
+	}
+
+	var args []interface{}
+	s := bytes.NewBufferString("UPDATE ")
+	s.WriteString(dialect.Quote(clauses.Table))
+	s.WriteString(" SET ")
+	if len(clauses.Assignments) > 0 {
+		for j, field := range clauses.Assignments[0] {
+			if j != 0 {
+				s.WriteString(", ")
+			}
+			s.WriteString(dialect.Quote(field.Field.DBName))
+			s.WriteString(" = ?")
+			args = append(args, field.Value.Interface())
 		}
+	}
+
+	if clauses.Conditions != nil {
+		_, err = clauses.Conditions.SQL.WriteTo(s)
+		args = append(args, clauses.Conditions.Args...)
+	}
+
+	if clauses.Order != nil {
+		_, err = clauses.Order.SQL.WriteTo(s)
+		args = append(args, clauses.Order.Args...)
+	}
+
+	if clauses.Limit != nil {
+		_, err = clauses.Limit.SQL.WriteTo(s)
+		args = append(args, clauses.Limit.Args...)
+	}
 
-		dests, err := toScanMap(columns, elem)
+	if err != nil {
+		return err
+	}
+
+	result, err := dialect.exec(contextFrom(tx), s.String(), args...)
+	if err == nil {
+		tx.RowsAffected, err = result.RowsAffected()
+	}
+	return err
+}
 
-		if err == nil {
-			err = rows.Scan(dests...)
+// sqliteExprTermLimit is a conservative guess at how many `WHEN ... THEN`
+// terms (rows times changed columns, summed over every CASE expression) a
+// single CASE-based UPDATE can hold before bumping into SQLite's
+// compile-time expression-tree depth limit (SQLITE_LIMIT_EXPR_DEPTH,
+// default 1000). Groups that would exceed it fall back to one UPDATE per
+// row, run inside a single transaction.
+const sqliteExprTermLimit = 900
+
+// batchUpdate updates multiple rows, keyed by primary key, in as few
+// statements as possible: rows are grouped by the set of columns they
+// change, and each group becomes either a single
+// `UPDATE ... SET col = CASE id WHEN ? THEN ? ... END WHERE id IN (...)`
+// statement, or, if that would exceed sqliteExprTermLimit, a sequence of
+// individual `UPDATE` statements executed inside one transaction.
+func (dialect *Dialect) batchUpdate(tx *gorm.DB, table string, assignments [][]*model.Field) error {
+	ctx := contextFrom(tx)
+
+	groups, err := groupAssignmentsByColumns(assignments)
+	if err != nil {
+		return err
+	}
+
+	sqlTx, err := dialect.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var rowsAffected int64
+	for _, group := range groups {
+		if len(group.rows)*len(group.columns) > sqliteExprTermLimit {
+			affected, err := execIndividualUpdates(ctx, sqlTx, dialect, table, group)
+			if err != nil {
+				sqlTx.Rollback()
+				return err
+			}
+			rowsAffected += affected
+			continue
 		}
 
+		query, args := buildCaseUpdateSQL(dialect, table, group)
+		result, err := dialect.traceExec(ctx, func(query string, args ...interface{}) (sql.Result, error) {
+			return sqlTx.ExecContext(ctx, query, args...)
+		}, query, args)
 		if err != nil {
+			sqlTx.Rollback()
 			return err
 		}
-
-		if isSlice {
-			results.Set(reflect.Append(results, elem))
+		affected, err := result.RowsAffected()
+		if err != nil {
+			sqlTx.Rollback()
+			return err
 		}
+		rowsAffected += affected
 	}
 
-	return
+	if err := sqlTx.Commit(); err != nil {
+		return err
+	}
+	tx.RowsAffected = rowsAffected
+	return nil
 }
 
-func toScanMap(columns []string, elem reflect.Value) (results []interface{}, err error) {
-	var ignored interface{}
-	results = make([]interface{}, len(columns))
+// assignmentGroup is a set of rows that all change the same columns, ready
+// to be rendered as one CASE-based UPDATE.
+type assignmentGroup struct {
+	primaryKey string
+	columns    []string
+	rows       []map[string]*model.Field // keyed by column name, including the primary key
+}
 
-	switch elem.Kind() {
-	case reflect.Map:
-		for idx, column := range columns {
-			var value interface{}
-			elem.SetMapIndex(reflect.ValueOf(column), reflect.ValueOf(value))
-			results[idx] = &value
-		}
-	case reflect.Struct:
-		fieldsMap := model.Parse(elem.Addr().Interface()).FieldsMap()
-		for idx, column := range columns {
-			if f, ok := fieldsMap[column]; ok {
-				results[idx] = f.Value.Interface()
-			} else {
-				results[idx] = &ignored
-			}
+// groupAssignmentsByColumns buckets assignments by the set of non-primary
+// columns each row changes, preserving first-seen order within each bucket.
+func groupAssignmentsByColumns(assignments [][]*model.Field) ([]*assignmentGroup, error) {
+	groups := map[string]*assignmentGroup{}
+	var order []string
+
+	for _, fields := range assignments {
+		primaryKey, row, columns, err := indexAssignmentRow(fields)
+		if err != nil {
+			return nil, err
 		}
-	case reflect.Ptr:
-		if elem.IsNil() {
-			elem.Set(reflect.New(elem.Type().Elem()))
+
+		key := strings.Join(columns, ",")
+		group, ok := groups[key]
+		if !ok {
+			group = &assignmentGroup{primaryKey: primaryKey, columns: columns}
+			groups[key] = group
+			order = append(order, key)
 		}
-		return toScanMap(columns, elem)
-	default:
-		return nil, errors.New("unsupported destination")
+		group.rows = append(group.rows, row)
 	}
-	return
-}
 
-func indirect(reflectValue reflect.Value) reflect.Value {
-	for reflectValue.Kind() == reflect.Ptr {
-		reflectValue = reflectValue.Elem()
+	result := make([]*assignmentGroup, len(order))
+	for i, key := range order {
+		result[i] = groups[key]
 	}
-	return reflectValue
+	return result, nil
 }
 
-// Update update
-func (dialect *Dialect) Update(tx *gorm.DB) (err error) {
-	var (
-		args            []interface{}
-		tableNameChan   = sqlbuilder.GetTable(tx)
-		conditionsChan  = sqlbuilder.BuildConditions(tx)
-		assignmentsChan = sqlbuilder.GetAssignmentFields(tx)
-		orderChan       = sqlbuilder.BuildOrderCondition(tx)
-		limitChan       = sqlbuilder.BuildLimitCondition(tx)
-	)
+// indexAssignmentRow splits one row's assignment fields into its primary
+// key column and the (sorted, so identical sets group together) list of
+// other columns it changes.
+func indexAssignmentRow(fields []*model.Field) (primaryKey string, row map[string]*model.Field, columns []string, err error) {
+	row = map[string]*model.Field{}
+	for _, field := range fields {
+		row[field.Field.DBName] = field
+		if field.Field.IsPrimaryKey || field.Field.DBName == "id" {
+			primaryKey = field.Field.DBName
+		}
+	}
+	if primaryKey == "" {
+		return "", nil, nil, errors.New("sqlite: batch update requires a primary key field")
+	}
+	for column := range row {
+		if column != primaryKey {
+			columns = append(columns, column)
+		}
+	}
+	sort.Strings(columns)
+	return primaryKey, row, columns, nil
+}
 
+// buildCaseUpdateSQL renders group as a single CASE-based UPDATE statement.
+func buildCaseUpdateSQL(dialect *Dialect, table string, group *assignmentGroup) (string, []interface{}) {
+	var args []interface{}
 	s := bytes.NewBufferString("UPDATE ")
-	s.WriteString(dialect.Quote(<-tableNameChan))
+	s.WriteString(dialect.Quote(table))
 	s.WriteString(" SET ")
-	if assignments := <-assignmentsChan; len(assignments) > 0 {
-		for _, fields := range assignments {
-			for _, field := range fields {
-				s.WriteString(dialect.Quote(field.Field.DBName))
-				s.WriteString(" = ?")
-				args = append(args, field.Value.Interface())
-			}
-			// TODO update with multiple records
+
+	for i, column := range group.columns {
+		if i != 0 {
+			s.WriteString(", ")
+		}
+		s.WriteString(dialect.Quote(column))
+		s.WriteString(" = CASE ")
+		s.WriteString(dialect.Quote(group.primaryKey))
+		for _, row := range group.rows {
+			s.WriteString(" WHEN ? THEN ?")
+			args = append(args, row[group.primaryKey].Value.Interface(), row[column].Value.Interface())
 		}
+		s.WriteString(" ELSE ")
+		s.WriteString(dialect.Quote(column))
+		s.WriteString(" END")
 	}
 
-	if len(tx.Statement.Conditions) > 0 {
-		builder := <-conditionsChan
-		_, err = builder.SQL.WriteTo(s)
-		args = append(args, builder.Args...)
+	s.WriteString(" WHERE ")
+	s.WriteString(dialect.Quote(group.primaryKey))
+	s.WriteString(" IN (")
+	for i, row := range group.rows {
+		if i != 0 {
+			s.WriteString(", ")
+		}
+		s.WriteString("?")
+		args = append(args, row[group.primaryKey].Value.Interface())
 	}
+	s.WriteString(")")
 
-	if builder := <-orderChan; builder != nil {
-		_, err = builder.SQL.WriteTo(s)
-		args = append(args, builder.Args...)
-	}
+	return s.String(), args
+}
 
-	if builder := <-limitChan; builder != nil {
-		_, err = builder.SQL.WriteTo(s)
-		args = append(args, builder.Args...)
+// execIndividualUpdates runs one UPDATE per row in group, inside sqlTx,
+// returning the aggregate RowsAffected.
+func execIndividualUpdates(ctx context.Context, sqlTx *sql.Tx, dialect *Dialect, table string, group *assignmentGroup) (int64, error) {
+	var total int64
+	for _, row := range group.rows {
+		var args []interface{}
+		s := bytes.NewBufferString("UPDATE ")
+		s.WriteString(dialect.Quote(table))
+		s.WriteString(" SET ")
+		for i, column := range group.columns {
+			if i != 0 {
+				s.WriteString(", ")
+			}
+			s.WriteString(dialect.Quote(column))
+			s.WriteString(" = ?")
+			args = append(args, row[column].Value.Interface())
+		}
+		s.WriteString(" WHERE ")
+		s.WriteString(dialect.Quote(group.primaryKey))
+		s.WriteString(" = ?")
+		args = append(args, row[group.primaryKey].Value.Interface())
+
+		result, err := dialect.traceExec(ctx, func(query string, args ...interface{}) (sql.Result, error) {
+			return sqlTx.ExecContext(ctx, query, args...)
+		}, s.String(), args)
+		if err != nil {
+			return total, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += affected
 	}
-
-	_, err = dialect.DB.Exec(s.String(), args...)
-	return err
+	return total, nil
 }
 
 // Delete delete
 func (dialect *Dialect) Delete(tx *gorm.DB) (err error) {
-	var (
-		args           []interface{}
-		tableNameChan  = sqlbuilder.GetTable(tx)
-		conditionsChan = sqlbuilder.BuildConditions(tx)
-		orderChan      = sqlbuilder.BuildOrderCondition(tx)
-		limitChan      = sqlbuilder.BuildLimitCondition(tx)
-	)
+	clauses, err := sqlbuilder.Build(tx)
+	if err != nil {
+		return err
+	}
+
+	var args []interface{}
 	s := bytes.NewBufferString("DELETE FROM ")
-	s.WriteString(dialect.Quote(<-tableNameChan))
+	s.WriteString(dialect.Quote(clauses.Table))
 
-	if len(tx.Statement.Conditions) > 0 {
-		builder := <-conditionsChan
-		_, err = builder.SQL.WriteTo(s)
-		args = append(args, builder.Args...)
+	if clauses.Conditions != nil {
+		_, err = clauses.Conditions.SQL.WriteTo(s)
+		args = append(args, clauses.Conditions.Args...)
 	}
 
-	if builder := <-orderChan; builder != nil {
-		_, err = builder.SQL.WriteTo(s)
-		args = append(args, builder.Args...)
+	if clauses.Order != nil {
+		_, err = clauses.Order.SQL.WriteTo(s)
+		args = append(args, clauses.Order.Args...)
 	}
 
-	if builder := <-limitChan; builder != nil {
-		_, err = builder.SQL.WriteTo(s)
-		args = append(args, builder.Args...)
+	if clauses.Limit != nil {
+		_, err = clauses.Limit.SQL.WriteTo(s)
+		args = append(args, clauses.Limit.Args...)
 	}
 
-	_, err = dialect.DB.Exec(s.String(), args...)
-	return
-}
-
-// AutoMigrate auto migrate database
-func (dialect *Dialect) AutoMigrate(value interface{}) (err error) {
-	// create table
-
-	// create missed column
-
-	// safe upgrade some fields (like size, change data type)
-
-	// create missed foreign key
-
-	// create missed index
-	return nil
-}
+	if err != nil {
+		return err
+	}
 
-func (dialect *Dialect) HasTable(name string) bool {
-	return false
+	result, err := dialect.exec(contextFrom(tx), s.String(), args...)
+	if err == nil {
+		tx.RowsAffected, err = result.RowsAffected()
+	}
+	return
 }
 
-func (dialect *Dialect) CreateTable(value interface{}) error {
-	s := schema.Parse(value)
-	return nil
-}
+// AutoMigrate, HasTable, and CreateTable are implemented in migration.go.
@@ -0,0 +1,229 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/jinzhu/gorm/model"
+	"github.com/jinzhu/gorm/schema"
+)
+
+// newField builds a *model.Field the way the assignment builders
+// (dialects/common/sqlbuilder) would: a static schema.Field description
+// paired with the row's runtime value.
+func newField(column string, isPrimaryKey bool, value interface{}) *model.Field {
+	return &model.Field{
+		Field: &schema.Field{DBName: column, IsPrimaryKey: isPrimaryKey},
+		Value: reflect.ValueOf(value),
+	}
+}
+
+func TestGroupAssignmentsByColumns(t *testing.T) {
+	assignments := [][]*model.Field{
+		{newField("id", true, int64(1)), newField("name", false, "a"), newField("age", false, 10)},
+		{newField("id", true, int64(2)), newField("name", false, "b"), newField("age", false, 20)},
+		{newField("id", true, int64(3)), newField("name", false, "c")}, // touches only `name`
+	}
+
+	groups, err := groupAssignmentsByColumns(assignments)
+	if err != nil {
+		t.Fatalf("groupAssignmentsByColumns: %v", err)
+	}
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups (one per column set), got %d", len(groups))
+	}
+
+	byColumns := map[string]*assignmentGroup{}
+	for _, g := range groups {
+		byColumns[fmt.Sprint(g.columns)] = g
+	}
+
+	full, ok := byColumns[fmt.Sprint([]string{"age", "name"})]
+	if !ok {
+		t.Fatalf("expected a group updating [age name], got groups %+v", groups)
+	}
+	if len(full.rows) != 2 {
+		t.Fatalf("expected 2 rows in the [age name] group, got %d", len(full.rows))
+	}
+
+	partial, ok := byColumns[fmt.Sprint([]string{"name"})]
+	if !ok {
+		t.Fatalf("expected a group updating only [name], got groups %+v", groups)
+	}
+	if len(partial.rows) != 1 {
+		t.Fatalf("expected 1 row in the [name]-only group, got %d", len(partial.rows))
+	}
+}
+
+func TestIndexAssignmentRowRequiresPrimaryKey(t *testing.T) {
+	_, _, _, err := indexAssignmentRow([]*model.Field{newField("name", false, "a")})
+	if err == nil {
+		t.Fatal("expected an error when no field is the primary key")
+	}
+}
+
+func TestBuildCaseUpdateSQL(t *testing.T) {
+	group := &assignmentGroup{
+		primaryKey: "id",
+		columns:    []string{"name"},
+		rows: []map[string]*model.Field{
+			{"id": newField("id", true, int64(1)), "name": newField("name", false, "a")},
+			{"id": newField("id", true, int64(2)), "name": newField("name", false, "b")},
+		},
+	}
+
+	query, args := buildCaseUpdateSQL(&Dialect{}, "users", group)
+
+	const want = `UPDATE "users" SET "name" = CASE "id" WHEN ? THEN ? WHEN ? THEN ? ELSE "name" END WHERE "id" IN (?, ?)`
+	if query != want {
+		t.Fatalf("query mismatch:\n got:  %s\nwant: %s", query, want)
+	}
+	wantArgs := []interface{}{int64(1), "a", int64(2), "b", int64(1), int64(2)}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Fatalf("args mismatch: got %#v, want %#v", args, wantArgs)
+	}
+}
+
+// fakeResult is a driver.Result reporting a fixed RowsAffected count.
+type fakeResult struct{ rows int64 }
+
+func (r fakeResult) LastInsertId() (int64, error) { return 0, nil }
+func (r fakeResult) RowsAffected() (int64, error) { return r.rows, nil }
+
+// fakeTx is a driver.Tx that counts commits and rollbacks so tests can
+// assert on transaction outcome without a real database underneath.
+type fakeTx struct {
+	commits   *int32
+	rollbacks *int32
+}
+
+func (t *fakeTx) Commit() error   { atomic.AddInt32(t.commits, 1); return nil }
+func (t *fakeTx) Rollback() error { atomic.AddInt32(t.rollbacks, 1); return nil }
+
+// fakeConn is a minimal driver.Conn that executes every statement
+// successfully, except the failAt-th one (1-indexed), which fails. It
+// lets TestBatchUpdateRollsBackOnPartialFailure exercise batchUpdate's
+// transaction handling without a real SQLite driver.
+type fakeConn struct {
+	mu        sync.Mutex
+	execCount int
+	failAt    int
+	// rowsPerExec[i], if present, is the RowsAffected reported for the
+	// (i+1)-th ExecContext call; calls beyond its length default to 1.
+	rowsPerExec []int64
+	commits     int32
+	rollbacks   int32
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return nil, errors.New("fakeConn: Prepare is not supported, use ExecContext")
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return &fakeTx{commits: &c.commits, rollbacks: &c.rollbacks}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	c.mu.Lock()
+	c.execCount++
+	n := c.execCount
+	c.mu.Unlock()
+
+	if c.failAt > 0 && n == c.failAt {
+		return nil, fmt.Errorf("fakeConn: forced failure on exec #%d", n)
+	}
+
+	rows := int64(1)
+	if n-1 < len(c.rowsPerExec) {
+		rows = c.rowsPerExec[n-1]
+	}
+	return fakeResult{rows: rows}, nil
+}
+
+type fakeDriver struct{ conn *fakeConn }
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) { return d.conn, nil }
+
+var fakeDriverSeq int32
+
+// newFakeDB registers a fresh driver name (sql.Register panics on reuse)
+// backed by a single fakeConn, and returns both so the caller can inspect
+// commit/rollback counts (tests) or drive a tight loop against it
+// (benchmarks) without a real SQLite driver.
+func newFakeDB(t testing.TB, failAt int) (*sql.DB, *fakeConn) {
+	t.Helper()
+	conn := &fakeConn{failAt: failAt}
+	name := fmt.Sprintf("sqlite-fake-%d", atomic.AddInt32(&fakeDriverSeq, 1))
+	sql.Register(name, &fakeDriver{conn: conn})
+
+	db, err := sql.Open(name, "")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	return db, conn
+}
+
+func TestBatchUpdateRollsBackOnPartialFailure(t *testing.T) {
+	db, conn := newFakeDB(t, 2) // fail the second group's UPDATE
+	defer db.Close()
+
+	assignments := [][]*model.Field{
+		{newField("id", true, int64(1)), newField("name", false, "a")},
+		{newField("id", true, int64(2)), newField("name", false, "b")},
+		{newField("id", true, int64(3)), newField("age", false, 30)}, // distinct column set -> 2nd group
+	}
+
+	dialect := &Dialect{DB: db}
+	tx := &gorm.DB{Statement: &gorm.Statement{}}
+
+	if err := dialect.batchUpdate(tx, "users", assignments); err == nil {
+		t.Fatal("expected batchUpdate to return the forced failure")
+	}
+
+	if got := atomic.LoadInt32(&conn.commits); got != 0 {
+		t.Fatalf("expected no commits on partial failure, got %d", got)
+	}
+	if got := atomic.LoadInt32(&conn.rollbacks); got != 1 {
+		t.Fatalf("expected exactly 1 rollback on partial failure, got %d", got)
+	}
+}
+
+func TestBatchUpdateCommitsOnSuccess(t *testing.T) {
+	db, conn := newFakeDB(t, 0) // never fail
+	defer db.Close()
+
+	assignments := [][]*model.Field{
+		{newField("id", true, int64(1)), newField("name", false, "a")},
+		{newField("id", true, int64(2)), newField("name", false, "b")},
+	}
+
+	conn.rowsPerExec = []int64{2} // one group, one CASE statement touching both rows
+
+	dialect := &Dialect{DB: db}
+	tx := &gorm.DB{Statement: &gorm.Statement{}}
+
+	if err := dialect.batchUpdate(tx, "users", assignments); err != nil {
+		t.Fatalf("batchUpdate: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&conn.commits); got != 1 {
+		t.Fatalf("expected exactly 1 commit on success, got %d", got)
+	}
+	if got := atomic.LoadInt32(&conn.rollbacks); got != 0 {
+		t.Fatalf("expected no rollbacks on success, got %d", got)
+	}
+	if tx.RowsAffected != 2 {
+		t.Fatalf("expected RowsAffected 2, got %d", tx.RowsAffected)
+	}
+}
@@ -0,0 +1,176 @@
+// Package migrator provides a minimal, gormigrate-style versioned migration
+// runner on top of *gorm.DB: migrations are registered as plain Go funcs
+// keyed by a sortable ID, and Migrator keeps track of which ones already ran
+// in a bookkeeping table.
+package migrator
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/jinzhu/gorm"
+)
+
+// migrationsTable is the bookkeeping table Migrator uses to record applied
+// migration IDs.
+const migrationsTable = "gorm_migrations"
+
+// appliedMigration is the row shape of migrationsTable.
+type appliedMigration struct {
+	ID string `gorm:"column:id;primary_key"`
+}
+
+// TableName stores appliedMigration rows in migrationsTable.
+func (appliedMigration) TableName() string { return migrationsTable }
+
+// Migration is a single versioned schema or data change. ID must sort in
+// the order migrations should run (e.g. "20060102150405_add_users").
+type Migration struct {
+	ID       string
+	Migrate  func(*gorm.DB) error
+	Rollback func(*gorm.DB) error
+}
+
+// Migrator runs a fixed set of Migrations against db, recording progress in
+// migrationsTable so repeated runs only apply what's new.
+type Migrator struct {
+	db *gorm.DB
+}
+
+// New returns a Migrator bound to db, creating the bookkeeping table if it
+// doesn't already exist.
+func New(db *gorm.DB) (*Migrator, error) {
+	m := &Migrator{db: db}
+	if err := db.AutoMigrate(&appliedMigration{}); err != nil {
+		return nil, fmt.Errorf("migrator: create bookkeeping table: %w", err)
+	}
+	return m, nil
+}
+
+// RunMigrations applies every migration in migrations that hasn't already
+// been recorded as applied, in ascending ID order, each inside its own
+// transaction.
+func (m *Migrator) RunMigrations(migrations []*Migration) error {
+	sorted := sortedByID(migrations)
+
+	applied, err := m.appliedIDs()
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range sorted {
+		if applied[migration.ID] {
+			continue
+		}
+		if err := m.run(migration); err != nil {
+			return fmt.Errorf("migrator: migration %q: %w", migration.ID, err)
+		}
+	}
+	return nil
+}
+
+// MigrateTo applies every pending migration up to and including id.
+func (m *Migrator) MigrateTo(migrations []*Migration, id string) error {
+	sorted := sortedByID(migrations)
+
+	applied, err := m.appliedIDs()
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range sorted {
+		if migration.ID > id {
+			break
+		}
+		if applied[migration.ID] {
+			continue
+		}
+		if err := m.run(migration); err != nil {
+			return fmt.Errorf("migrator: migration %q: %w", migration.ID, err)
+		}
+	}
+	return nil
+}
+
+// RollbackLast rolls back the most recently applied migration among
+// migrations.
+func (m *Migrator) RollbackLast(migrations []*Migration) error {
+	sorted := sortedByID(migrations)
+
+	applied, err := m.appliedIDs()
+	if err != nil {
+		return err
+	}
+
+	for i := len(sorted) - 1; i >= 0; i-- {
+		migration := sorted[i]
+		if applied[migration.ID] {
+			return m.rollback(migration)
+		}
+	}
+	return nil
+}
+
+// RollbackTo rolls back every applied migration after id, in descending ID
+// order, leaving id itself applied.
+func (m *Migrator) RollbackTo(migrations []*Migration, id string) error {
+	sorted := sortedByID(migrations)
+
+	applied, err := m.appliedIDs()
+	if err != nil {
+		return err
+	}
+
+	for i := len(sorted) - 1; i >= 0; i-- {
+		migration := sorted[i]
+		if migration.ID <= id {
+			break
+		}
+		if applied[migration.ID] {
+			if err := m.rollback(migration); err != nil {
+				return fmt.Errorf("migrator: rollback %q: %w", migration.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) run(migration *Migration) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Migrate(tx); err != nil {
+			return err
+		}
+		return tx.Create(&appliedMigration{ID: migration.ID}).Error
+	})
+}
+
+func (m *Migrator) rollback(migration *Migration) error {
+	if migration.Rollback == nil {
+		return fmt.Errorf("migration %q has no Rollback func", migration.ID)
+	}
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Rollback(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&appliedMigration{}, "id = ?", migration.ID).Error
+	})
+}
+
+func (m *Migrator) appliedIDs() (map[string]bool, error) {
+	var rows []appliedMigration
+	if err := m.db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		ids[row.ID] = true
+	}
+	return ids, nil
+}
+
+func sortedByID(migrations []*Migration) []*Migration {
+	sorted := make([]*Migration, len(migrations))
+	copy(sorted, migrations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ID < sorted[j].ID })
+	return sorted
+}
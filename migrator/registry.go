@@ -0,0 +1,19 @@
+package migrator
+
+// registry holds migrations registered at package init time via Register,
+// so callers don't have to thread a []*Migration literal through to the
+// place RunMigrations is invoked (typically a CLI entrypoint).
+var registry []*Migration
+
+// Register adds a migration to the package-level registry. Call it from an
+// init() func in the file that defines the migration.
+func Register(migration *Migration) {
+	registry = append(registry, migration)
+}
+
+// Registered returns every migration registered so far via Register.
+func Registered() []*Migration {
+	out := make([]*Migration, len(registry))
+	copy(out, registry)
+	return out
+}
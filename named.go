@@ -0,0 +1,39 @@
+package gorm
+
+import "fmt"
+
+// NamedQuerier is implemented by a Dialect that supports named-parameter
+// (`:name`/`@name`) queries; see dialects/sqlite for the reference
+// implementation.
+type NamedQuerier interface {
+	NamedQuery(tx *DB, query string, arg interface{}) error
+}
+
+// NamedExecer is implemented by a Dialect that supports named-parameter
+// (`:name`/`@name`) exec statements.
+type NamedExecer interface {
+	NamedExec(tx *DB, query string, arg interface{}) error
+}
+
+// NamedQuery runs query, with `:name`/`@name` placeholders bound from arg,
+// scanning the result into db.Statement.Dest. db.Dialect must implement
+// NamedQuerier; dialects/sqlite does.
+func (db *DB) NamedQuery(query string, arg interface{}) *DB {
+	if querier, ok := db.Dialect.(NamedQuerier); ok {
+		db.AddError(querier.NamedQuery(db, query, arg))
+	} else {
+		db.AddError(fmt.Errorf("gorm: dialect %T does not support NamedQuery", db.Dialect))
+	}
+	return db
+}
+
+// NamedExec runs query, with `:name`/`@name` placeholders bound from arg.
+// db.Dialect must implement NamedExecer; dialects/sqlite does.
+func (db *DB) NamedExec(query string, arg interface{}) *DB {
+	if execer, ok := db.Dialect.(NamedExecer); ok {
+		db.AddError(execer.NamedExec(db, query, arg))
+	} else {
+		db.AddError(fmt.Errorf("gorm: dialect %T does not support NamedExec", db.Dialect))
+	}
+	return db
+}